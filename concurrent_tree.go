@@ -0,0 +1,306 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+	"sync"
+)
+
+// ConcurrentQuadTree is a thread-safe QuadTree. Rather than a single global
+// mutex, every node carries its own sync.RWMutex: Insert and Delete take the
+// write lock on the node they mutate (and briefly on the parent while
+// installing new children during a subdivide), while Search and Query take a
+// read lock on each node and release it before descending into the next one.
+// This is lock coupling with a gap rather than true hand-over-hand locking
+// (which would acquire the child's lock before releasing the parent's): a
+// goroutine can be between the parent unlock and the child lock with neither
+// held. That's safe here only because the tree never merges, so a released
+// parent's children remain valid once installed; a future compaction/merge
+// operation would need to close that gap (e.g. acquire the child lock before
+// releasing the parent's) rather than relying on this reasoning. This still
+// lets reads and writes in unrelated subtrees proceed without contending on
+// a single lock, which matters once a background ingestion goroutine is
+// adding points while other goroutines query concurrently.
+type ConcurrentQuadTree[T Pointer] struct {
+	mu           sync.RWMutex
+	boundary     Rect
+	capacity     int
+	points       []T
+	northWest    *ConcurrentQuadTree[T]
+	northEast    *ConcurrentQuadTree[T]
+	southWest    *ConcurrentQuadTree[T]
+	southEast    *ConcurrentQuadTree[T]
+	isSubdivided bool
+	depth        int
+	maxDepth     int
+}
+
+// NewConcurrentQuadTree creates a new ConcurrentQuadTree with the default
+// max subdivision depth.
+func NewConcurrentQuadTree[T Pointer](boundary Rect, capacity int) *ConcurrentQuadTree[T] {
+	return newConcurrentQuadTree[T](boundary, capacity, 0, DefaultMaxDepth)
+}
+
+func newConcurrentQuadTree[T Pointer](boundary Rect, capacity, depth, maxDepth int) *ConcurrentQuadTree[T] {
+	return &ConcurrentQuadTree[T]{
+		boundary: boundary,
+		capacity: capacity,
+		points:   make([]T, 0),
+		depth:    depth,
+		maxDepth: maxDepth,
+	}
+}
+
+// Insert adds an item to the tree.
+func (qt *ConcurrentQuadTree[T]) Insert(item T) bool {
+	qt.mu.Lock()
+
+	if !qt.boundary.Contains(item.Location()) {
+		qt.mu.Unlock()
+		return false
+	}
+
+	if len(qt.points) < qt.capacity && !qt.isSubdivided {
+		qt.points = append(qt.points, item)
+		qt.mu.Unlock()
+		return true
+	}
+
+	if !qt.isSubdivided {
+		if qt.depth >= qt.maxDepth {
+			// Can't subdivide any further; let this leaf grow past
+			// capacity rather than recursing on degenerate input.
+			qt.points = append(qt.points, item)
+			qt.mu.Unlock()
+			return true
+		}
+		qt.subdivideLocked()
+	}
+
+	children := [4]*ConcurrentQuadTree[T]{qt.northWest, qt.northEast, qt.southWest, qt.southEast}
+	qt.mu.Unlock() // children are in place; hand off before descending
+
+	for _, child := range children {
+		if child.Insert(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// subdivideLocked splits qt into four quadrants. qt.mu must already be held
+// for writing.
+func (qt *ConcurrentQuadTree[T]) subdivideLocked() {
+	x := qt.boundary.X
+	y := qt.boundary.Y
+	w := qt.boundary.Width / 2
+	h := qt.boundary.Height / 2
+	childDepth := qt.depth + 1
+
+	qt.northWest = newConcurrentQuadTree[T](Rect{x, y, w, h}, qt.capacity, childDepth, qt.maxDepth)
+	qt.northEast = newConcurrentQuadTree[T](Rect{x + w, y, w, h}, qt.capacity, childDepth, qt.maxDepth)
+	qt.southWest = newConcurrentQuadTree[T](Rect{x, y + h, w, h}, qt.capacity, childDepth, qt.maxDepth)
+	qt.southEast = newConcurrentQuadTree[T](Rect{x + w, y + h, w, h}, qt.capacity, childDepth, qt.maxDepth)
+
+	qt.isSubdivided = true
+
+	points := qt.points
+	qt.points = nil
+	for _, item := range points {
+		// The new children aren't reachable by any other goroutine yet, so
+		// it's safe to insert into them while still holding qt.mu.
+		if qt.northWest.Insert(item) {
+			continue
+		}
+		if qt.northEast.Insert(item) {
+			continue
+		}
+		if qt.southWest.Insert(item) {
+			continue
+		}
+		qt.southEast.Insert(item)
+	}
+}
+
+// Delete removes a point from the tree.
+func (qt *ConcurrentQuadTree[T]) Delete(p Point) bool {
+	qt.mu.Lock()
+
+	if !qt.boundary.Contains(p) {
+		qt.mu.Unlock()
+		return false
+	}
+
+	for i, item := range qt.points {
+		if item.Location() == p {
+			qt.points = append(qt.points[:i], qt.points[i+1:]...)
+			qt.mu.Unlock()
+			return true
+		}
+	}
+
+	if !qt.isSubdivided {
+		qt.mu.Unlock()
+		return false
+	}
+
+	children := [4]*ConcurrentQuadTree[T]{qt.northWest, qt.northEast, qt.southWest, qt.southEast}
+	qt.mu.Unlock()
+
+	for _, child := range children {
+		if child.Delete(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search reports whether a point exists in the tree.
+func (qt *ConcurrentQuadTree[T]) Search(p Point) bool {
+	qt.mu.RLock()
+
+	if !qt.boundary.Contains(p) {
+		qt.mu.RUnlock()
+		return false
+	}
+
+	for _, item := range qt.points {
+		if item.Location() == p {
+			qt.mu.RUnlock()
+			return true
+		}
+	}
+
+	if !qt.isSubdivided {
+		qt.mu.RUnlock()
+		return false
+	}
+
+	children := [4]*ConcurrentQuadTree[T]{qt.northWest, qt.northEast, qt.southWest, qt.southEast}
+	qt.mu.RUnlock() // hand over to children before descending
+
+	for _, child := range children {
+		if child.Search(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Query returns all items within a given rectangle.
+func (qt *ConcurrentQuadTree[T]) Query(range_ Rect) []T {
+	qt.mu.RLock()
+
+	if !qt.boundary.Intersects(range_) {
+		qt.mu.RUnlock()
+		return make([]T, 0)
+	}
+
+	found := make([]T, 0, len(qt.points))
+	for _, item := range qt.points {
+		if range_.Contains(item.Location()) {
+			found = append(found, item)
+		}
+	}
+
+	if !qt.isSubdivided {
+		qt.mu.RUnlock()
+		return found
+	}
+
+	children := [4]*ConcurrentQuadTree[T]{qt.northWest, qt.northEast, qt.southWest, qt.southEast}
+	qt.mu.RUnlock()
+
+	for _, child := range children {
+		found = append(found, child.Query(range_)...)
+	}
+	return found
+}
+
+// QueryRadius returns all items within radius of center, pruning any
+// subtree whose boundary does not overlap the circle. See QuadTree.QueryRadius.
+func (qt *ConcurrentQuadTree[T]) QueryRadius(center Point, radius float64) []T {
+	qt.mu.RLock()
+
+	r2 := radius * radius
+	if distSquared(closestPointOnRect(qt.boundary, center), center) > r2 {
+		qt.mu.RUnlock()
+		return make([]T, 0)
+	}
+
+	found := make([]T, 0, len(qt.points))
+	for _, item := range qt.points {
+		if distSquared(item.Location(), center) <= r2 {
+			found = append(found, item)
+		}
+	}
+
+	if !qt.isSubdivided {
+		qt.mu.RUnlock()
+		return found
+	}
+
+	children := [4]*ConcurrentQuadTree[T]{qt.northWest, qt.northEast, qt.southWest, qt.southEast}
+	qt.mu.RUnlock()
+
+	for _, child := range children {
+		found = append(found, child.QueryRadius(center, radius)...)
+	}
+	return found
+}
+
+// KNearest returns the k items closest to center, nearest first. See
+// QuadTree.KNearest.
+func (qt *ConcurrentQuadTree[T]) KNearest(center Point, k int) []T {
+	if k <= 0 {
+		return nil
+	}
+
+	h := &neighborHeap[T]{}
+	qt.kNearest(center, k, h)
+
+	result := make([]T, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(h).(neighbor[T]).item
+	}
+	return result
+}
+
+// kNearest feeds candidates from this node into h under a read lock, then,
+// having released that lock, descends into children nearest-boundary-first,
+// pruning any child whose closest possible point is already farther than
+// the current k-th best.
+func (qt *ConcurrentQuadTree[T]) kNearest(center Point, k int, h *neighborHeap[T]) {
+	qt.mu.RLock()
+
+	for _, item := range qt.points {
+		d := distSquared(item.Location(), center)
+		if h.Len() < k {
+			heap.Push(h, neighbor[T]{item: item, distSq: d})
+		} else if d < (*h)[0].distSq {
+			heap.Pop(h)
+			heap.Push(h, neighbor[T]{item: item, distSq: d})
+		}
+	}
+
+	if !qt.isSubdivided {
+		qt.mu.RUnlock()
+		return
+	}
+
+	children := []*ConcurrentQuadTree[T]{qt.northWest, qt.northEast, qt.southWest, qt.southEast}
+	qt.mu.RUnlock()
+
+	sort.Slice(children, func(i, j int) bool {
+		return distSquared(closestPointOnRect(children[i].boundary, center), center) <
+			distSquared(closestPointOnRect(children[j].boundary, center), center)
+	})
+
+	for _, child := range children {
+		minDistSq := distSquared(closestPointOnRect(child.boundary, center), center)
+		if h.Len() == k && minDistSq > (*h)[0].distSq {
+			continue
+		}
+		child.kNearest(center, k, h)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// singleMutexQuadTree is the naive baseline ConcurrentQuadTree's per-node
+// locking is benchmarked against: one global mutex guards a plain QuadTree,
+// so every Insert and Query single-files through it regardless of which
+// subtree it actually touches.
+type singleMutexQuadTree[T Pointer] struct {
+	mu   sync.Mutex
+	tree *QuadTree[T]
+}
+
+func newSingleMutexQuadTree[T Pointer](boundary Rect, capacity int) *singleMutexQuadTree[T] {
+	return &singleMutexQuadTree[T]{tree: NewQuadTree[T](boundary, capacity)}
+}
+
+func (t *singleMutexQuadTree[T]) Insert(item T) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.Insert(item)
+}
+
+func (t *singleMutexQuadTree[T]) Query(r Rect) []T {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tree.Query(r)
+}
+
+// benchBoundary is the domain benchmark points are scattered across.
+var benchBoundary = Rect{X: 0, Y: 0, Width: 10000, Height: 10000}
+
+func randomPoint(rng *rand.Rand) Point {
+	return Point{X: rng.Float64() * benchBoundary.Width, Y: rng.Float64() * benchBoundary.Height}
+}
+
+// runMixedBenchmark seeds a tree with 1000 points, then drives insert and
+// query concurrently across GOMAXPROCS goroutines, biased 1-in-10 towards
+// inserts, mirroring a background ingestion goroutine racing with frequent
+// reads.
+func runMixedBenchmark(b *testing.B, insert func(Point) bool, query func(Rect) []Point) {
+	seed := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		insert(randomPoint(seed))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		rng := rand.New(rand.NewSource(rand.Int63()))
+		i := 0
+		for pb.Next() {
+			if i%10 == 0 {
+				insert(randomPoint(rng))
+			} else {
+				p := randomPoint(rng)
+				query(Rect{X: p.X - 50, Y: p.Y - 50, Width: 100, Height: 100})
+			}
+			i++
+		}
+	})
+}
+
+// BenchmarkMixedSingleMutex measures the single-global-mutex baseline under
+// a mixed read/write workload.
+func BenchmarkMixedSingleMutex(b *testing.B) {
+	t := newSingleMutexQuadTree[Point](benchBoundary, 4)
+	runMixedBenchmark(b, t.Insert, t.Query)
+}
+
+// BenchmarkMixedPerNodeLocking measures ConcurrentQuadTree's per-node
+// sync.RWMutex locking under the same mixed read/write workload.
+func BenchmarkMixedPerNodeLocking(b *testing.B) {
+	t := NewConcurrentQuadTree[Point](benchBoundary, 4)
+	runMixedBenchmark(b, t.Insert, t.Query)
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"container/heap"
 	"fmt"
+	"sort"
 )
 
 // Point represents a 2D point
@@ -9,57 +11,104 @@ type Point struct {
 	X, Y float64
 }
 
+// Location returns the point itself, satisfying the Pointer interface so a
+// bare Point can be stored directly in a QuadTree.
+func (p Point) Location() Point {
+	return p
+}
+
+// Pointer is implemented by anything that can report the Point at which it
+// should be stored in a QuadTree, allowing the tree to carry an arbitrary
+// payload alongside its location.
+type Pointer interface {
+	Location() Point
+}
+
 // Rect represents a rectangle
 type Rect struct {
 	X, Y, Width, Height float64
 }
 
-// QuadTree represents the quadtree data structure
-type QuadTree struct {
+// DefaultMaxDepth bounds how many times a QuadTree built via NewQuadTree or
+// NewQuadTreeAABB will subdivide. Without a cap, many coincident or
+// near-coincident points would force Insert into subdividing forever.
+const DefaultMaxDepth = 8
+
+// QuadTree represents the quadtree data structure. T is the payload stored
+// at each point; it must know its own location via Pointer.
+type QuadTree[T Pointer] struct {
 	Boundary     Rect
 	Capacity     int
-	Points       []Point
-	NorthWest    *QuadTree
-	NorthEast    *QuadTree
-	SouthWest    *QuadTree
-	SouthEast    *QuadTree
+	Points       []T
+	NorthWest    *QuadTree[T]
+	NorthEast    *QuadTree[T]
+	SouthWest    *QuadTree[T]
+	SouthEast    *QuadTree[T]
 	IsSubdivided bool
+	Depth        int
+	MaxDepth     int
+}
+
+// NewQuadTree creates a new QuadTree with the default max subdivision depth
+func NewQuadTree[T Pointer](boundary Rect, capacity int) *QuadTree[T] {
+	return newQuadTree[T](boundary, capacity, 0, DefaultMaxDepth)
 }
 
-// NewQuadTree creates a new QuadTree
-func NewQuadTree(boundary Rect, capacity int) *QuadTree {
-	return &QuadTree{
+// NewQuadTreeAABB creates a new QuadTree whose boundary is expressed as a
+// center point plus a half-dimension, as in the classic center/half-width
+// quadtree formulation, rather than the X/Y/Width/Height form NewQuadTree
+// takes. maxDepth caps how deep Subdivide will recurse.
+func NewQuadTreeAABB[T Pointer](center, half Point, capacity, maxDepth int) *QuadTree[T] {
+	boundary := Rect{
+		X:      center.X - half.X,
+		Y:      center.Y - half.Y,
+		Width:  2 * half.X,
+		Height: 2 * half.Y,
+	}
+	return newQuadTree[T](boundary, capacity, 0, maxDepth)
+}
+
+func newQuadTree[T Pointer](boundary Rect, capacity, depth, maxDepth int) *QuadTree[T] {
+	return &QuadTree[T]{
 		Boundary: boundary,
 		Capacity: capacity,
-		Points:   make([]Point, 0),
+		Points:   make([]T, 0),
+		Depth:    depth,
+		MaxDepth: maxDepth,
 	}
 }
 
-// Insert adds a point to the QuadTree
-func (qt *QuadTree) Insert(p Point) bool {
-	if !qt.Boundary.Contains(p) {
+// Insert adds an item to the QuadTree
+func (qt *QuadTree[T]) Insert(item T) bool {
+	if !qt.Boundary.Contains(item.Location()) {
 		return false
 	}
 
 	if len(qt.Points) < qt.Capacity && !qt.IsSubdivided {
-		qt.Points = append(qt.Points, p)
+		qt.Points = append(qt.Points, item)
 		return true
 	}
 
 	if !qt.IsSubdivided {
+		if qt.Depth >= qt.MaxDepth {
+			// Can't subdivide any further; let this leaf grow past
+			// Capacity rather than recursing on degenerate input.
+			qt.Points = append(qt.Points, item)
+			return true
+		}
 		qt.Subdivide()
 	}
 
-	if qt.NorthWest.Insert(p) {
+	if qt.NorthWest.Insert(item) {
 		return true
 	}
-	if qt.NorthEast.Insert(p) {
+	if qt.NorthEast.Insert(item) {
 		return true
 	}
-	if qt.SouthWest.Insert(p) {
+	if qt.SouthWest.Insert(item) {
 		return true
 	}
-	if qt.SouthEast.Insert(p) {
+	if qt.SouthEast.Insert(item) {
 		return true
 	}
 
@@ -67,33 +116,34 @@ func (qt *QuadTree) Insert(p Point) bool {
 }
 
 // Subdivide splits the QuadTree into four quadrants
-func (qt *QuadTree) Subdivide() {
+func (qt *QuadTree[T]) Subdivide() {
 	x := qt.Boundary.X
 	y := qt.Boundary.Y
 	w := qt.Boundary.Width / 2
 	h := qt.Boundary.Height / 2
+	childDepth := qt.Depth + 1
 
-	qt.NorthWest = NewQuadTree(Rect{x, y, w, h}, qt.Capacity)
-	qt.NorthEast = NewQuadTree(Rect{x + w, y, w, h}, qt.Capacity)
-	qt.SouthWest = NewQuadTree(Rect{x, y + h, w, h}, qt.Capacity)
-	qt.SouthEast = NewQuadTree(Rect{x + w, y + h, w, h}, qt.Capacity)
+	qt.NorthWest = newQuadTree[T](Rect{x, y, w, h}, qt.Capacity, childDepth, qt.MaxDepth)
+	qt.NorthEast = newQuadTree[T](Rect{x + w, y, w, h}, qt.Capacity, childDepth, qt.MaxDepth)
+	qt.SouthWest = newQuadTree[T](Rect{x, y + h, w, h}, qt.Capacity, childDepth, qt.MaxDepth)
+	qt.SouthEast = newQuadTree[T](Rect{x + w, y + h, w, h}, qt.Capacity, childDepth, qt.MaxDepth)
 
 	qt.IsSubdivided = true
 
-	for _, p := range qt.Points {
-		qt.Insert(p)
+	for _, item := range qt.Points {
+		qt.Insert(item)
 	}
 	qt.Points = nil
 }
 
-// Search finds a point in the QuadTree
-func (qt *QuadTree) Search(p Point) bool {
+// Search reports whether a point exists in the QuadTree
+func (qt *QuadTree[T]) Search(p Point) bool {
 	if !qt.Boundary.Contains(p) {
 		return false
 	}
 
-	for _, point := range qt.Points {
-		if point == p {
+	for _, item := range qt.Points {
+		if item.Location() == p {
 			return true
 		}
 	}
@@ -108,17 +158,17 @@ func (qt *QuadTree) Search(p Point) bool {
 	return false
 }
 
-// Query returns all points within a given rectangle
-func (qt *QuadTree) Query(range_ Rect) []Point {
-	found := make([]Point, 0)
+// Query returns all items within a given rectangle
+func (qt *QuadTree[T]) Query(range_ Rect) []T {
+	found := make([]T, 0)
 
 	if !qt.Boundary.Intersects(range_) {
 		return found
 	}
 
-	for _, p := range qt.Points {
-		if range_.Contains(p) {
-			found = append(found, p)
+	for _, item := range qt.Points {
+		if range_.Contains(item.Location()) {
+			found = append(found, item)
 		}
 	}
 
@@ -133,13 +183,13 @@ func (qt *QuadTree) Query(range_ Rect) []Point {
 }
 
 // Delete removes a point from the QuadTree
-func (qt *QuadTree) Delete(p Point) bool {
+func (qt *QuadTree[T]) Delete(p Point) bool {
 	if !qt.Boundary.Contains(p) {
 		return false
 	}
 
-	for i, point := range qt.Points {
-		if point == p {
+	for i, item := range qt.Points {
+		if item.Location() == p {
 			qt.Points = append(qt.Points[:i], qt.Points[i+1:]...)
 			return true
 		}
@@ -163,6 +213,129 @@ func (qt *QuadTree) Delete(p Point) bool {
 	return false
 }
 
+// QueryRadius returns all items within radius of center, pruning any
+// subtree whose Boundary does not overlap the circle.
+func (qt *QuadTree[T]) QueryRadius(center Point, radius float64) []T {
+	found := make([]T, 0)
+	r2 := radius * radius
+
+	if distSquared(closestPointOnRect(qt.Boundary, center), center) > r2 {
+		return found
+	}
+
+	for _, item := range qt.Points {
+		if distSquared(item.Location(), center) <= r2 {
+			found = append(found, item)
+		}
+	}
+
+	if qt.IsSubdivided {
+		found = append(found, qt.NorthWest.QueryRadius(center, radius)...)
+		found = append(found, qt.NorthEast.QueryRadius(center, radius)...)
+		found = append(found, qt.SouthWest.QueryRadius(center, radius)...)
+		found = append(found, qt.SouthEast.QueryRadius(center, radius)...)
+	}
+
+	return found
+}
+
+// KNearest returns the k items closest to center, nearest first, using a
+// best-first traversal backed by a bounded max-heap.
+func (qt *QuadTree[T]) KNearest(center Point, k int) []T {
+	if k <= 0 {
+		return nil
+	}
+
+	h := &neighborHeap[T]{}
+	qt.kNearest(center, k, h)
+
+	result := make([]T, h.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(h).(neighbor[T]).item
+	}
+	return result
+}
+
+// kNearest feeds candidates from this node into h, then descends into
+// children nearest-boundary-first, pruning any child whose closest possible
+// point is already farther than the current k-th best.
+func (qt *QuadTree[T]) kNearest(center Point, k int, h *neighborHeap[T]) {
+	for _, item := range qt.Points {
+		d := distSquared(item.Location(), center)
+		if h.Len() < k {
+			heap.Push(h, neighbor[T]{item: item, distSq: d})
+		} else if d < (*h)[0].distSq {
+			heap.Pop(h)
+			heap.Push(h, neighbor[T]{item: item, distSq: d})
+		}
+	}
+
+	if !qt.IsSubdivided {
+		return
+	}
+
+	children := []*QuadTree[T]{qt.NorthWest, qt.NorthEast, qt.SouthWest, qt.SouthEast}
+	sort.Slice(children, func(i, j int) bool {
+		return distSquared(closestPointOnRect(children[i].Boundary, center), center) <
+			distSquared(closestPointOnRect(children[j].Boundary, center), center)
+	})
+
+	for _, child := range children {
+		minDistSq := distSquared(closestPointOnRect(child.Boundary, center), center)
+		if h.Len() == k && minDistSq > (*h)[0].distSq {
+			continue
+		}
+		child.kNearest(center, k, h)
+	}
+}
+
+// neighbor pairs a stored item with its squared distance from a query center.
+type neighbor[T Pointer] struct {
+	item   T
+	distSq float64
+}
+
+// neighborHeap is a max-heap on distSq, so the worst of the current k-best
+// candidates always sits at the root and can be evicted in O(log k).
+type neighborHeap[T Pointer] []neighbor[T]
+
+func (h neighborHeap[T]) Len() int            { return len(h) }
+func (h neighborHeap[T]) Less(i, j int) bool  { return h[i].distSq > h[j].distSq }
+func (h neighborHeap[T]) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *neighborHeap[T]) Push(x interface{}) { *h = append(*h, x.(neighbor[T])) }
+func (h *neighborHeap[T]) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// closestPointOnRect returns the point within r closest to p, used to bound
+// the minimum possible distance from p to any point the subtree could hold.
+func closestPointOnRect(r Rect, p Point) Point {
+	return Point{
+		X: clamp(p.X, r.X, r.X+r.Width),
+		Y: clamp(p.Y, r.Y, r.Y+r.Height),
+	}
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func distSquared(a, b Point) float64 {
+	dx := a.X - b.X
+	dy := a.Y - b.Y
+	return dx*dx + dy*dy
+}
+
 // Contains checks if a point is within the rectangle
 func (r Rect) Contains(p Point) bool {
 	return p.X >= r.X && p.X < r.X+r.Width &&
@@ -183,9 +356,15 @@ type MapPoint struct {
 	Data interface{}
 }
 
-// Map represents the zoomable map
+// Map represents the zoomable map. Its QuadTree is a ConcurrentQuadTree so
+// AddPoint and GetVisiblePoints can safely be called concurrently from
+// multiple goroutines, e.g. a background ingestion goroutine adding POIs
+// while other goroutines query it. The viewport fields below (ZoomLevel,
+// CenterX, CenterY, ViewportWidth, ViewportHeight) are not synchronized;
+// ZoomIn/ZoomOut/Pan must not be called concurrently with each other or with
+// GetVisiblePoints.
 type Map struct {
-	QuadTree       *QuadTree
+	QuadTree       *ConcurrentQuadTree[MapPoint]
 	ZoomLevel      int
 	CenterX        float64
 	CenterY        float64
@@ -196,7 +375,7 @@ type Map struct {
 // NewMap creates a new Map
 func NewMap(boundary Rect, capacity int) *Map {
 	return &Map{
-		QuadTree:       NewQuadTree(boundary, capacity),
+		QuadTree:       NewConcurrentQuadTree[MapPoint](boundary, capacity),
 		ZoomLevel:      0,
 		CenterX:        boundary.X + boundary.Width/2,
 		CenterY:        boundary.Y + boundary.Height/2,
@@ -207,7 +386,7 @@ func NewMap(boundary Rect, capacity int) *Map {
 
 // AddPoint adds a point to the map
 func (m *Map) AddPoint(p MapPoint) {
-	m.QuadTree.Insert(p.Point)
+	m.QuadTree.Insert(p)
 }
 
 // ZoomIn increases the zoom level and adjusts the viewport
@@ -232,7 +411,8 @@ func (m *Map) Pan(dx, dy float64) {
 	m.CenterY += dy
 }
 
-// GetVisiblePoints returns all points visible in the current viewport
+// GetVisiblePoints returns all points visible in the current viewport, with
+// their associated data intact
 func (m *Map) GetVisiblePoints() []MapPoint {
 	viewportRect := Rect{
 		X:      m.CenterX - m.ViewportWidth/2,
@@ -240,13 +420,19 @@ func (m *Map) GetVisiblePoints() []MapPoint {
 		Width:  m.ViewportWidth,
 		Height: m.ViewportHeight,
 	}
-	points := m.QuadTree.Query(viewportRect)
+	return m.QuadTree.Query(viewportRect)
+}
 
-	mapPoints := make([]MapPoint, len(points))
-	for i, p := range points {
-		mapPoints[i] = MapPoint{Point: p}
-	}
-	return mapPoints
+// GetNearbyPoints returns all points within radius of center, with their
+// associated data intact, e.g. for "find nearby POIs".
+func (m *Map) GetNearbyPoints(center Point, radius float64) []MapPoint {
+	return m.QuadTree.QueryRadius(center, radius)
+}
+
+// GetKNearestPoints returns the k points closest to center, nearest first,
+// with their associated data intact.
+func (m *Map) GetKNearestPoints(center Point, k int) []MapPoint {
+	return m.QuadTree.KNearest(center, k)
 }
 
 func main() {
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"sort"
+)
+
+// quadTreeData is the on-disk representation of a QuadTree, used by both the
+// JSON and gob encodings so a populated tree can be snapshotted and reloaded
+// without re-inserting every point.
+type quadTreeData[T Pointer] struct {
+	Boundary     Rect
+	Capacity     int
+	Points       []T
+	NorthWest    *QuadTree[T]
+	NorthEast    *QuadTree[T]
+	SouthWest    *QuadTree[T]
+	SouthEast    *QuadTree[T]
+	IsSubdivided bool
+	Depth        int
+	MaxDepth     int
+}
+
+func (qt *QuadTree[T]) toData() quadTreeData[T] {
+	return quadTreeData[T]{
+		Boundary:     qt.Boundary,
+		Capacity:     qt.Capacity,
+		Points:       qt.Points,
+		NorthWest:    qt.NorthWest,
+		NorthEast:    qt.NorthEast,
+		SouthWest:    qt.SouthWest,
+		SouthEast:    qt.SouthEast,
+		IsSubdivided: qt.IsSubdivided,
+		Depth:        qt.Depth,
+		MaxDepth:     qt.MaxDepth,
+	}
+}
+
+func (qt *QuadTree[T]) fromData(d quadTreeData[T]) {
+	qt.Boundary = d.Boundary
+	qt.Capacity = d.Capacity
+	qt.Points = d.Points
+	if qt.Points == nil {
+		qt.Points = make([]T, 0)
+	}
+	qt.NorthWest = d.NorthWest
+	qt.NorthEast = d.NorthEast
+	qt.SouthWest = d.SouthWest
+	qt.SouthEast = d.SouthEast
+	qt.IsSubdivided = d.IsSubdivided
+	qt.Depth = d.Depth
+	qt.MaxDepth = d.MaxDepth
+}
+
+// MarshalJSON snapshots the tree's boundary, capacity, subdivision state and
+// points so it can be reloaded later with UnmarshalJSON.
+func (qt *QuadTree[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(qt.toData())
+}
+
+// UnmarshalJSON restores a tree previously written by MarshalJSON.
+func (qt *QuadTree[T]) UnmarshalJSON(data []byte) error {
+	var d quadTreeData[T]
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	qt.fromData(d)
+	return nil
+}
+
+// GobEncode snapshots the tree for gob, mirroring MarshalJSON.
+func (qt *QuadTree[T]) GobEncode() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(qt.toData()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode restores a tree previously written by GobEncode.
+func (qt *QuadTree[T]) GobDecode(data []byte) error {
+	var d quadTreeData[T]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&d); err != nil {
+		return err
+	}
+	qt.fromData(d)
+	return nil
+}
+
+// toPlain snapshots qt, and recursively its children, into a plain QuadTree
+// so the existing MarshalJSON/GobEncode machinery can be reused instead of
+// duplicated.
+func (qt *ConcurrentQuadTree[T]) toPlain() *QuadTree[T] {
+	qt.mu.RLock()
+	defer qt.mu.RUnlock()
+
+	plain := &QuadTree[T]{
+		Boundary:     qt.boundary,
+		Capacity:     qt.capacity,
+		Points:       append([]T(nil), qt.points...),
+		IsSubdivided: qt.isSubdivided,
+		Depth:        qt.depth,
+		MaxDepth:     qt.maxDepth,
+	}
+	if qt.isSubdivided {
+		plain.NorthWest = qt.northWest.toPlain()
+		plain.NorthEast = qt.northEast.toPlain()
+		plain.SouthWest = qt.southWest.toPlain()
+		plain.SouthEast = qt.southEast.toPlain()
+	}
+	return plain
+}
+
+// fromPlain builds a ConcurrentQuadTree out of a plain QuadTree, recursively
+// wrapping its children, so a tree produced by BuildQuadTree or restored by
+// UnmarshalJSON/GobDecode can back a ConcurrentQuadTree (and so a Map).
+func fromPlain[T Pointer](qt *QuadTree[T]) *ConcurrentQuadTree[T] {
+	ct := &ConcurrentQuadTree[T]{
+		boundary:     qt.Boundary,
+		capacity:     qt.Capacity,
+		points:       qt.Points,
+		isSubdivided: qt.IsSubdivided,
+		depth:        qt.Depth,
+		maxDepth:     qt.MaxDepth,
+	}
+	if ct.points == nil {
+		ct.points = make([]T, 0)
+	}
+	if qt.IsSubdivided {
+		ct.northWest = fromPlain[T](qt.NorthWest)
+		ct.northEast = fromPlain[T](qt.NorthEast)
+		ct.southWest = fromPlain[T](qt.SouthWest)
+		ct.southEast = fromPlain[T](qt.SouthEast)
+	}
+	return ct
+}
+
+// MarshalJSON snapshots the tree the same way QuadTree.MarshalJSON does, so
+// a Map's ConcurrentQuadTree can be written to disk directly.
+func (qt *ConcurrentQuadTree[T]) MarshalJSON() ([]byte, error) {
+	return qt.toPlain().MarshalJSON()
+}
+
+// UnmarshalJSON restores a tree previously written by MarshalJSON, replacing
+// qt's contents under its write lock.
+func (qt *ConcurrentQuadTree[T]) UnmarshalJSON(data []byte) error {
+	var plain QuadTree[T]
+	if err := plain.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	qt.restoreFrom(fromPlain[T](&plain))
+	return nil
+}
+
+// GobEncode snapshots the tree for gob, mirroring MarshalJSON.
+func (qt *ConcurrentQuadTree[T]) GobEncode() ([]byte, error) {
+	return qt.toPlain().GobEncode()
+}
+
+// GobDecode restores a tree previously written by GobEncode.
+func (qt *ConcurrentQuadTree[T]) GobDecode(data []byte) error {
+	var plain QuadTree[T]
+	if err := plain.GobDecode(data); err != nil {
+		return err
+	}
+	qt.restoreFrom(fromPlain[T](&plain))
+	return nil
+}
+
+// restoreFrom replaces qt's contents with restored's under qt's write lock,
+// shared by UnmarshalJSON and GobDecode.
+func (qt *ConcurrentQuadTree[T]) restoreFrom(restored *ConcurrentQuadTree[T]) {
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+	qt.boundary = restored.boundary
+	qt.capacity = restored.capacity
+	qt.points = restored.points
+	qt.northWest = restored.northWest
+	qt.northEast = restored.northEast
+	qt.southWest = restored.southWest
+	qt.southEast = restored.southEast
+	qt.isSubdivided = restored.isSubdivided
+	qt.depth = restored.depth
+	qt.maxDepth = restored.maxDepth
+}
+
+// BuildConcurrentQuadTree bulk-loads a ConcurrentQuadTree the same way
+// BuildQuadTree does for a plain QuadTree, via Morton-order partitioning
+// rather than capacity-many calls to Insert. This is what makes the Map use
+// case from BuildQuadTree's original rationale reachable: Map.QuadTree is a
+// ConcurrentQuadTree, not a QuadTree.
+func BuildConcurrentQuadTree[T Pointer](boundary Rect, capacity int, items []T) *ConcurrentQuadTree[T] {
+	return fromPlain[T](BuildQuadTree(boundary, capacity, items))
+}
+
+// mortonItem pairs an item with its precomputed Morton code so BuildQuadTree
+// only has to compute it once per item, not once per level of recursion.
+type mortonItem[T Pointer] struct {
+	item T
+	code uint32
+}
+
+// BuildQuadTree constructs a QuadTree in O(n log n): items are sorted once
+// by Morton (Z-order) code within boundary, then recursively split into
+// quadrants by grouping contiguous runs of that sorted slice sharing a
+// common Morton prefix, rather than re-scanning and Rect.Contains-testing
+// every item at every level. This gives better balance than capacity-many
+// calls to Insert in arbitrary order.
+func BuildQuadTree[T Pointer](boundary Rect, capacity int, items []T) *QuadTree[T] {
+	sorted := make([]mortonItem[T], len(items))
+	for i, item := range items {
+		sorted[i] = mortonItem[T]{item: item, code: mortonCode(item.Location(), boundary)}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].code < sorted[j].code })
+	return buildQuadTree(boundary, capacity, 0, DefaultMaxDepth, sorted)
+}
+
+func buildQuadTree[T Pointer](boundary Rect, capacity, depth, maxDepth int, items []mortonItem[T]) *QuadTree[T] {
+	qt := newQuadTree[T](boundary, capacity, depth, maxDepth)
+
+	if len(items) <= capacity || depth >= maxDepth {
+		for _, it := range items {
+			qt.Points = append(qt.Points, it.item)
+		}
+		return qt
+	}
+
+	x := boundary.X
+	y := boundary.Y
+	w := boundary.Width / 2
+	h := boundary.Height / 2
+	childDepth := depth + 1
+
+	nwBoundary := Rect{x, y, w, h}
+	neBoundary := Rect{x + w, y, w, h}
+	swBoundary := Rect{x, y + h, w, h}
+	seBoundary := Rect{x + w, y + h, w, h}
+
+	nw, ne, sw, se := splitByMortonPrefix(items, depth)
+
+	qt.IsSubdivided = true
+	qt.NorthWest = buildQuadTree(nwBoundary, capacity, childDepth, maxDepth, nw)
+	qt.NorthEast = buildQuadTree(neBoundary, capacity, childDepth, maxDepth, ne)
+	qt.SouthWest = buildQuadTree(swBoundary, capacity, childDepth, maxDepth, sw)
+	qt.SouthEast = buildQuadTree(seBoundary, capacity, childDepth, maxDepth, se)
+
+	return qt
+}
+
+// splitByMortonPrefix partitions items, which must already be sorted by
+// Morton code and share a common prefix through depth (true for the root
+// call, and preserved by construction on every recursive call below), into
+// its four quadrants at depth. Because every item here agrees on the bits
+// above this level, the 2-bit field at this level is the most significant
+// remaining one, so it is monotonic non-decreasing across the slice and the
+// four quadrants form contiguous runs findable by binary search rather than
+// a full Rect.Contains scan.
+func splitByMortonPrefix[T Pointer](items []mortonItem[T], depth int) (nw, ne, sw, se []mortonItem[T]) {
+	quadrantOf := func(code uint32) uint32 {
+		shift := uint(2 * (mortonBits - 1 - depth))
+		return (code >> shift) & 3
+	}
+
+	neStart := sort.Search(len(items), func(i int) bool { return quadrantOf(items[i].code) >= 1 })
+	swStart := sort.Search(len(items), func(i int) bool { return quadrantOf(items[i].code) >= 2 })
+	seStart := sort.Search(len(items), func(i int) bool { return quadrantOf(items[i].code) >= 3 })
+
+	return items[:neStart], items[neStart:swStart], items[swStart:seStart], items[seStart:]
+}
+
+// mortonBits is the number of bits used per axis when computing a Morton
+// code, giving a 2^mortonBits x 2^mortonBits grid resolution within
+// boundary.
+const mortonBits = 16
+
+// mortonCode normalizes p into boundary as integer coordinates in
+// [0, 2^mortonBits) and interleaves their bits to produce a Z-order code:
+// points close in space end up close in code.
+func mortonCode(p Point, boundary Rect) uint32 {
+	return interleaveBits(normalizeAxis(p.X, boundary.X, boundary.Width)) |
+		interleaveBits(normalizeAxis(p.Y, boundary.Y, boundary.Height))<<1
+}
+
+func normalizeAxis(v, min, size float64) uint32 {
+	const gridSize = float64(uint32(1) << mortonBits)
+
+	if size <= 0 {
+		return 0
+	}
+	t := (v - min) / size
+	switch {
+	case t < 0:
+		t = 0
+	case t >= 1:
+		t = 1 - 1.0/gridSize
+	}
+	return uint32(t * gridSize)
+}
+
+// interleaveBits spreads v's low mortonBits bits out so there is a zero bit
+// between each original bit, e.g. b3b2b1b0 -> 0b3_0b2_0b1_0b0. Two spread
+// values OR'd together (one shifted left by one) form a Morton code.
+func interleaveBits(v uint32) uint32 {
+	v &= 0x0000ffff
+	v = (v | (v << 8)) & 0x00FF00FF
+	v = (v | (v << 4)) & 0x0F0F0F0F
+	v = (v | (v << 2)) & 0x33333333
+	v = (v | (v << 1)) & 0x55555555
+	return v
+}
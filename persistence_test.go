@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// bruteForceQuery returns every point in pts contained by r, for comparison
+// against tree-based Query results.
+func bruteForceQuery(pts []Point, r Rect) []Point {
+	found := make([]Point, 0)
+	for _, p := range pts {
+		if r.Contains(p) {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+func pointSet(pts []Point) map[Point]int {
+	set := make(map[Point]int, len(pts))
+	for _, p := range pts {
+		set[p]++
+	}
+	return set
+}
+
+// TestBuildQuadTreeMortonGrouping checks that splitByMortonPrefix's
+// contiguous-range partitioning produces a tree equivalent to inserting
+// every point one at a time: every point is Search-able, a full-boundary
+// Query returns exactly the input set, and range queries against random
+// rectangles agree with a linear scan.
+func TestBuildQuadTreeMortonGrouping(t *testing.T) {
+	cases := []struct {
+		name string
+		n    int
+		seed int64
+	}{
+		{"empty", 0, 1},
+		{"single", 1, 2},
+		{"fewer_than_capacity", 3, 3},
+		{"many_random", 500, 4},
+		{"clustered", 500, 5},
+	}
+
+	boundary := Rect{X: 0, Y: 0, Width: 1000, Height: 1000}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(tc.seed))
+			pts := make([]Point, tc.n)
+			for i := range pts {
+				if tc.name == "clustered" {
+					// Bias points into a small region so many share a long
+					// Morton prefix, stressing the split boundaries.
+					pts[i] = Point{X: 400 + rng.Float64()*10, Y: 400 + rng.Float64()*10}
+				} else {
+					pts[i] = Point{X: rng.Float64() * boundary.Width, Y: rng.Float64() * boundary.Height}
+				}
+			}
+
+			qt := BuildQuadTree[Point](boundary, 4, pts)
+
+			for _, p := range pts {
+				if !qt.Search(p) {
+					t.Fatalf("point %v not found via Search", p)
+				}
+			}
+
+			got := pointSet(qt.Query(boundary))
+			want := pointSet(pts)
+			if len(got) != len(want) {
+				t.Fatalf("full-boundary Query returned %d distinct points, want %d", len(got), len(want))
+			}
+			for p, wantCount := range want {
+				if got[p] != wantCount {
+					t.Fatalf("point %v: Query returned %d copies, want %d", p, got[p], wantCount)
+				}
+			}
+
+			for i := 0; i < 10; i++ {
+				r := Rect{
+					X:      rng.Float64() * boundary.Width,
+					Y:      rng.Float64() * boundary.Height,
+					Width:  rng.Float64() * boundary.Width / 4,
+					Height: rng.Float64() * boundary.Height / 4,
+				}
+				gotRange := pointSet(qt.Query(r))
+				wantRange := pointSet(bruteForceQuery(pts, r))
+				if len(gotRange) != len(wantRange) {
+					t.Fatalf("Query(%v) returned %d distinct points, want %d", r, len(gotRange), len(wantRange))
+				}
+				for p, wantCount := range wantRange {
+					if gotRange[p] != wantCount {
+						t.Fatalf("Query(%v): point %v got %d copies, want %d", r, p, gotRange[p], wantCount)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// bruteForceRadius returns every point in pts within radius of center, for
+// comparison against QueryRadius.
+func bruteForceRadius(pts []Point, center Point, radius float64) []Point {
+	found := make([]Point, 0)
+	r2 := radius * radius
+	for _, p := range pts {
+		if distSquared(p, center) <= r2 {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// bruteForceKNearest returns the k points in pts closest to center, nearest
+// first, for comparison against KNearest.
+func bruteForceKNearest(pts []Point, center Point, k int) []Point {
+	if k <= 0 {
+		return nil
+	}
+	sorted := append([]Point(nil), pts...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return distSquared(sorted[i], center) < distSquared(sorted[j], center)
+	})
+	if k > len(sorted) {
+		k = len(sorted)
+	}
+	return sorted[:k]
+}
+
+// radiusQuerier and kNearestQuerier let the same test body drive both
+// QuadTree and ConcurrentQuadTree.
+type radiusQuerier interface {
+	QueryRadius(center Point, radius float64) []Point
+	KNearest(center Point, k int) []Point
+}
+
+func buildTestTrees(pts []Point, boundary Rect, capacity int) map[string]radiusQuerier {
+	qt := NewQuadTree[Point](boundary, capacity)
+	for _, p := range pts {
+		qt.Insert(p)
+	}
+	ct := NewConcurrentQuadTree[Point](boundary, capacity)
+	for _, p := range pts {
+		ct.Insert(p)
+	}
+	return map[string]radiusQuerier{"QuadTree": qt, "ConcurrentQuadTree": ct}
+}
+
+func TestQueryRadiusMatchesBruteForce(t *testing.T) {
+	boundary := Rect{X: 0, Y: 0, Width: 1000, Height: 1000}
+	rng := rand.New(rand.NewSource(11))
+	pts := make([]Point, 400)
+	for i := range pts {
+		pts[i] = Point{X: rng.Float64() * boundary.Width, Y: rng.Float64() * boundary.Height}
+	}
+
+	trees := buildTestTrees(pts, boundary, 4)
+
+	for name, tree := range trees {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 20; i++ {
+				center := Point{X: rng.Float64() * boundary.Width, Y: rng.Float64() * boundary.Height}
+				radius := rng.Float64() * 300
+
+				got := pointSet(tree.QueryRadius(center, radius))
+				want := pointSet(bruteForceRadius(pts, center, radius))
+				if len(got) != len(want) {
+					t.Fatalf("center=%v radius=%v: got %d points, want %d", center, radius, len(got), len(want))
+				}
+				for p, wantCount := range want {
+					if got[p] != wantCount {
+						t.Fatalf("center=%v radius=%v: point %v got %d copies, want %d", center, radius, p, got[p], wantCount)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKNearestMatchesBruteForce(t *testing.T) {
+	boundary := Rect{X: 0, Y: 0, Width: 1000, Height: 1000}
+	rng := rand.New(rand.NewSource(12))
+	pts := make([]Point, 200)
+	for i := range pts {
+		pts[i] = Point{X: rng.Float64() * boundary.Width, Y: rng.Float64() * boundary.Height}
+	}
+
+	trees := buildTestTrees(pts, boundary, 4)
+
+	ks := []int{-1, 0, 1, 5, len(pts), len(pts) + 50}
+
+	for name, tree := range trees {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 10; i++ {
+				center := Point{X: rng.Float64() * boundary.Width, Y: rng.Float64() * boundary.Height}
+				for _, k := range ks {
+					got := tree.KNearest(center, k)
+					want := bruteForceKNearest(pts, center, k)
+
+					if len(got) != len(want) {
+						t.Fatalf("center=%v k=%d: got %d results, want %d", center, k, len(got), len(want))
+					}
+					for i := range got {
+						if distSquared(got[i], center) != distSquared(want[i], center) {
+							t.Fatalf("center=%v k=%d: result %d at distance %v, want %v (nearest-first order violated)",
+								center, k, i, distSquared(got[i], center), distSquared(want[i], center))
+						}
+					}
+				}
+			}
+		})
+	}
+}
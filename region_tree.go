@@ -0,0 +1,156 @@
+package main
+
+// RegionTree is a region (image) quadtree over a 2^n x 2^n grid of cells,
+// each holding a value of type T. Unlike QuadTree, which indexes arbitrary
+// payloads by point location, RegionTree partitions a raster: every cell in
+// the domain holds a value, and runs of equal-valued cells are compressed
+// into a single leaf node, making it well suited to map layers such as
+// zoom-dependent tile rendering.
+type RegionTree[T comparable] struct {
+	size int // side length of the padded 2^n x 2^n domain
+	root *regionNode[T]
+}
+
+// regionNode is either a leaf holding a single value for its whole cell
+// range, or split into four equally-sized children (NW, NE, SW, SE).
+type regionNode[T comparable] struct {
+	x, y, size int // covers cells [x, x+size) x [y, y+size)
+	leaf       bool
+	value      T
+	children   [4]*regionNode[T]
+}
+
+// NewRegionTree creates a RegionTree covering a width x height domain,
+// padded up to the next power of two, with every cell initialized to fill.
+func NewRegionTree[T comparable](width, height int, fill T) *RegionTree[T] {
+	size := 1
+	for size < width || size < height {
+		size *= 2
+	}
+	return &RegionTree[T]{
+		size: size,
+		root: &regionNode[T]{x: 0, y: 0, size: size, leaf: true, value: fill},
+	}
+}
+
+// Get returns the value stored at (x, y), and false if (x, y) is outside
+// the tree's domain.
+func (rt *RegionTree[T]) Get(x, y int) (T, bool) {
+	if !rt.inBounds(x, y) {
+		var zero T
+		return zero, false
+	}
+	return rt.root.get(x, y), true
+}
+
+func (n *regionNode[T]) get(x, y int) T {
+	if n.leaf {
+		return n.value
+	}
+	return n.child(x, y).get(x, y)
+}
+
+// Set stores v at (x, y), splitting leaves as necessary to isolate the cell
+// and merging any sibling group of four equal-valued leaves back into one
+// after the write. It reports false without modifying the tree if (x, y) is
+// outside the tree's domain.
+func (rt *RegionTree[T]) Set(x, y int, v T) bool {
+	if !rt.inBounds(x, y) {
+		return false
+	}
+	rt.root.set(x, y, v)
+	return true
+}
+
+// inBounds reports whether (x, y) falls within the tree's padded domain.
+func (rt *RegionTree[T]) inBounds(x, y int) bool {
+	return x >= 0 && x < rt.size && y >= 0 && y < rt.size
+}
+
+func (n *regionNode[T]) set(x, y int, v T) {
+	if n.size == 1 {
+		n.value = v
+		return
+	}
+
+	if n.leaf {
+		if n.value == v {
+			return
+		}
+		n.split()
+	}
+
+	n.child(x, y).set(x, y, v)
+	n.tryMerge()
+}
+
+// child returns whichever of n's four children contains (x, y).
+func (n *regionNode[T]) child(x, y int) *regionNode[T] {
+	half := n.size / 2
+	idx := 0
+	if x >= n.x+half {
+		idx |= 1
+	}
+	if y >= n.y+half {
+		idx |= 2
+	}
+	return n.children[idx]
+}
+
+// split turns a leaf into four child leaves carrying its current value.
+func (n *regionNode[T]) split() {
+	half := n.size / 2
+	v := n.value
+	n.children[0] = &regionNode[T]{x: n.x, y: n.y, size: half, leaf: true, value: v}
+	n.children[1] = &regionNode[T]{x: n.x + half, y: n.y, size: half, leaf: true, value: v}
+	n.children[2] = &regionNode[T]{x: n.x, y: n.y + half, size: half, leaf: true, value: v}
+	n.children[3] = &regionNode[T]{x: n.x + half, y: n.y + half, size: half, leaf: true, value: v}
+	n.leaf = false
+}
+
+// tryMerge collapses n back into a single leaf if all four of its children
+// are leaves sharing the same value.
+func (n *regionNode[T]) tryMerge() {
+	if n.leaf {
+		return
+	}
+	first := n.children[0]
+	if !first.leaf {
+		return
+	}
+	for _, c := range n.children[1:] {
+		if !c.leaf || c.value != first.value {
+			return
+		}
+	}
+	n.leaf = true
+	n.value = first.value
+	n.children = [4]*regionNode[T]{}
+}
+
+// Tile is a maximal axis-aligned run of cells sharing a single value.
+type Tile[T comparable] struct {
+	Region Rect
+	Value  T
+}
+
+// Tiles returns the compressed rectangular runs making up the tree, one
+// Tile per leaf node.
+func (rt *RegionTree[T]) Tiles() []Tile[T] {
+	tiles := make([]Tile[T], 0)
+	rt.root.collectTiles(&tiles)
+	return tiles
+}
+
+func (n *regionNode[T]) collectTiles(tiles *[]Tile[T]) {
+	if n.leaf {
+		*tiles = append(*tiles, Tile[T]{
+			Region: Rect{X: float64(n.x), Y: float64(n.y), Width: float64(n.size), Height: float64(n.size)},
+			Value:  n.value,
+		})
+		return
+	}
+	for _, c := range n.children {
+		c.collectTiles(tiles)
+	}
+}